@@ -0,0 +1,175 @@
+// Package emlparse walks the MIME tree of a parsed email message.
+//
+// net/mail and mime/multipart only expose one level of a message at a
+// time: callers have to notice a part is itself multipart/* and spin up
+// their own multipart.Reader to go deeper. WalkParts does that recursion
+// for you and hands back leaf parts with their transfer encoding already
+// decoded, so callers can focus on what to do with the content instead of
+// how to reach it.
+package emlparse
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/transform"
+)
+
+// MIMEPart is a single leaf part encountered while walking a message's MIME
+// tree. Reader already has any Content-Transfer-Encoding (base64,
+// quoted-printable, 7bit/8bit) removed, so callers can read decoded bytes
+// directly.
+type MIMEPart struct {
+	Header textproto.MIMEHeader // Raw headers of this part
+
+	ContentType string            // Lower-cased media type, e.g. "application/pdf"
+	TypeParams  map[string]string // Content-Type parameters (name, charset, boundary, ...)
+
+	Disposition string            // "attachment", "inline", or "" if absent/unparseable
+	DispParams  map[string]string // Content-Disposition parameters (filename, ...)
+
+	Depth int // Nesting depth; 0 for the top-level message body
+
+	Reader io.Reader // Part content with transfer encoding already decoded
+}
+
+// WalkParts recursively descends msg's MIME tree, invoking fn once for each
+// leaf (non-multipart) part it finds, however deeply nested inside
+// multipart/mixed, multipart/alternative, multipart/related,
+// multipart/signed, or any other multipart/* container. If fn returns an
+// error, the walk stops immediately and that error is returned.
+func WalkParts(msg *mail.Message, fn func(part *MIMEPart) error) error {
+	return walk(textproto.MIMEHeader(msg.Header), msg.Body, 0, fn)
+}
+
+// walk processes a single part identified by header/body. If the part is
+// itself a multipart/* container it recurses into each of its children,
+// otherwise it decodes the part and invokes fn.
+func walk(header textproto.MIMEHeader, body io.Reader, depth int, fn func(part *MIMEPart) error) error {
+	mediaType, typeParams, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		// Unparseable or absent Content-Type: treat it as an opaque leaf
+		// rather than failing the whole walk, mirroring how a simple
+		// text email with no Content-Type header is still processed.
+		mediaType = ""
+		typeParams = nil
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		boundary := typeParams["boundary"]
+		if boundary == "" {
+			return fmt.Errorf("multipart part at depth %d without boundary", depth)
+		}
+
+		mr := multipart.NewReader(body, boundary)
+		for {
+			p, err := mr.NextPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("reading multipart part at depth %d: %w", depth, err)
+			}
+			if err := walk(p.Header, p, depth+1, fn); err != nil {
+				return err
+			}
+		}
+	}
+
+	disposition, dispParams, err := mime.ParseMediaType(header.Get("Content-Disposition"))
+	if err != nil {
+		disposition = ""
+		dispParams = nil
+	}
+
+	part := &MIMEPart{
+		Header:      header,
+		ContentType: mediaType,
+		TypeParams:  typeParams,
+		Disposition: disposition,
+		DispParams:  dispParams,
+		Depth:       depth,
+		Reader:      decodeTransferEncoding(header.Get("Content-Transfer-Encoding"), body),
+	}
+	return fn(part)
+}
+
+// decodeTransferEncoding wraps r in a reader that undoes the named
+// Content-Transfer-Encoding. 7bit, 8bit, binary, and unrecognized or empty
+// encodings pass the content through unchanged.
+func decodeTransferEncoding(cte string, r io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(cte)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, r)
+	case "quoted-printable":
+		return quotedprintable.NewReader(r)
+	default:
+		return r
+	}
+}
+
+// createCharsetReader returns a CharsetReader function suitable for
+// mime.WordDecoder, mapping charset names to decoders from
+// golang.org/x/text/encoding.
+func createCharsetReader(charset string, input io.Reader) (io.Reader, error) {
+	enc, err := htmlindex.Get(charset)
+	if err != nil {
+		return nil, fmt.Errorf("unhandled charset %q: %w", charset, err)
+	}
+	return transform.NewReader(input, enc.NewDecoder()), nil
+}
+
+// DecodeHeaderWord decodes a MIME "encoded-word" header value (e.g.
+// "=?UTF-8?B?...?="), falling back to the original value if it cannot be
+// decoded.
+func DecodeHeaderWord(value string) string {
+	value = strings.TrimSpace(value)
+	decoder := &mime.WordDecoder{CharsetReader: createCharsetReader}
+	decoded, err := decoder.DecodeHeader(value)
+	if err != nil {
+		return value
+	}
+	return decoded
+}
+
+// displayTypes are Content-Types a mail client renders inline as the
+// message body rather than treating as an attachment, even when marked
+// Content-Disposition: inline with a filename.
+var displayTypes = map[string]bool{
+	"text/plain": true,
+	"text/html":  true,
+}
+
+// IsAttachment reports whether a part's headers mark it as an attachment.
+// It recognizes the conventional Content-Disposition: attachment; the
+// non-conforming Content-Type: attachment; filename="..." that some
+// mailers emit in place of Content-Disposition; Content-Disposition:
+// inline with a filename parameter on a part whose Content-Type is not a
+// display type; and a bare 'name' parameter on the Content-Type with no
+// disposition at all. Missing or unparseable headers are treated as "not
+// an attachment" rather than an error.
+func IsAttachment(header textproto.MIMEHeader) bool {
+	contentType, typeParams, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	disposition, dispParams, _ := mime.ParseMediaType(header.Get("Content-Disposition"))
+
+	switch {
+	case disposition == "attachment":
+		return true
+	case contentType == "attachment" && typeParams["filename"] != "":
+		return true
+	case disposition == "inline" && dispParams["filename"] != "" && !displayTypes[contentType]:
+		return true
+	case disposition == "" && typeParams["name"] != "" && !displayTypes[contentType]:
+		return true
+	default:
+		return false
+	}
+}
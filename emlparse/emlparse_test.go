@@ -0,0 +1,216 @@
+package emlparse
+
+import (
+	"bytes"
+	"io"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+func TestCreateCharsetReader(t *testing.T) {
+	reader := strings.NewReader("Hello, World!")
+
+	charsetReader, err := createCharsetReader("utf-8", reader)
+	if err != nil {
+		t.Errorf("Expected no error for utf-8 charset, got: %v", err)
+	}
+	if charsetReader == nil {
+		t.Error("Expected non-nil reader for utf-8 charset")
+	}
+
+	_, err = createCharsetReader("invalid-charset", strings.NewReader("Hello, World!"))
+	if err == nil {
+		t.Error("Expected error for invalid charset, got nil")
+	}
+}
+
+func TestDecodeHeaderWord(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"plain.pdf", "plain.pdf"},
+		{"=?UTF-8?B?cmVwb3J0LnBkZg==?=", "report.pdf"},
+		{"", ""},
+	}
+
+	for _, tc := range testCases {
+		if got := DecodeHeaderWord(tc.input); got != tc.expected {
+			t.Errorf("DecodeHeaderWord(%q) = %q, want %q", tc.input, got, tc.expected)
+		}
+	}
+}
+
+func TestIsAttachment(t *testing.T) {
+	testCases := []struct {
+		description        string
+		contentType        string
+		contentDisposition string
+		expected           bool
+	}{
+		{
+			description:        "attachment disposition",
+			contentType:        "application/pdf",
+			contentDisposition: "attachment; filename=\"doc.pdf\"",
+			expected:           true,
+		},
+		{
+			description:        "no disposition, name parameter on Content-Type",
+			contentType:        "application/pdf; name=\"doc.pdf\"",
+			contentDisposition: "",
+			expected:           true,
+		},
+		{
+			description:        "non-conforming Content-Type: attachment",
+			contentType:        "attachment; filename=\"doc.pdf\"",
+			contentDisposition: "",
+			expected:           true,
+		},
+		{
+			description:        "inline with filename, non-display type",
+			contentType:        "application/pdf",
+			contentDisposition: "inline; filename=\"doc.pdf\"",
+			expected:           true,
+		},
+		{
+			description:        "inline with filename, but a display type",
+			contentType:        "text/html",
+			contentDisposition: "inline; filename=\"body.html\"",
+			expected:           false,
+		},
+		{
+			description:        "inline with no filename",
+			contentType:        "application/pdf",
+			contentDisposition: "inline",
+			expected:           false,
+		},
+		{
+			description:        "plain text body, no disposition or name",
+			contentType:        "text/plain",
+			contentDisposition: "",
+			expected:           false,
+		},
+		{
+			description:        "missing headers entirely",
+			contentType:        "",
+			contentDisposition: "",
+			expected:           false,
+		},
+	}
+
+	for _, tc := range testCases {
+		header := textproto.MIMEHeader{}
+		if tc.contentType != "" {
+			header.Set("Content-Type", tc.contentType)
+		}
+		if tc.contentDisposition != "" {
+			header.Set("Content-Disposition", tc.contentDisposition)
+		}
+
+		if got := IsAttachment(header); got != tc.expected {
+			t.Errorf("%s: IsAttachment() = %v, want %v", tc.description, got, tc.expected)
+		}
+	}
+}
+
+// readMessage parses a raw RFC 5322 message with CRLF line endings, as a
+// real .eml file would use.
+func readMessage(t *testing.T, raw string) *mail.Message {
+	t.Helper()
+	msg, err := mail.ReadMessage(strings.NewReader(strings.ReplaceAll(raw, "\n", "\r\n")))
+	if err != nil {
+		t.Fatalf("mail.ReadMessage: %v", err)
+	}
+	return msg
+}
+
+func TestWalkPartsNestedMultipart(t *testing.T) {
+	raw := `From: sender@example.com
+To: recipient@example.com
+Subject: nested
+MIME-Version: 1.0
+Content-Type: multipart/mixed; boundary="outer"
+
+--outer
+Content-Type: multipart/alternative; boundary="inner"
+
+--inner
+Content-Type: text/plain
+
+Hello!
+--inner--
+
+--outer
+Content-Type: application/pdf; name="doc.pdf"
+Content-Disposition: attachment; filename="doc.pdf"
+Content-Transfer-Encoding: base64
+
+aGVsbG8gd29ybGQ=
+--outer--
+`
+	msg := readMessage(t, raw)
+
+	// The underlying multipart.Part is only valid until the walk advances
+	// past it, so PDF content must be read from inside the callback.
+	var pdfCount int
+	var pdfDepth int
+	var pdfContent []byte
+	err := WalkParts(msg, func(part *MIMEPart) error {
+		if part.ContentType != "application/pdf" {
+			return nil
+		}
+		pdfCount++
+		pdfDepth = part.Depth
+		content, err := io.ReadAll(part.Reader)
+		if err != nil {
+			return err
+		}
+		pdfContent = content
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WalkParts returned error: %v", err)
+	}
+
+	if pdfCount != 1 {
+		t.Fatalf("expected 1 PDF part, got %d", pdfCount)
+	}
+	if pdfDepth != 1 {
+		t.Errorf("expected PDF part at depth 1, got %d", pdfDepth)
+	}
+	if !bytes.Equal(pdfContent, []byte("hello world")) {
+		t.Errorf("expected decoded content %q, got %q", "hello world", pdfContent)
+	}
+}
+
+func TestWalkPartsQuotedPrintable(t *testing.T) {
+	raw := `From: sender@example.com
+To: recipient@example.com
+Subject: qp
+MIME-Version: 1.0
+Content-Type: text/plain; charset="utf-8"
+Content-Transfer-Encoding: quoted-printable
+
+caf=C3=A9
+`
+	msg := readMessage(t, raw)
+
+	var bodies []string
+	err := WalkParts(msg, func(part *MIMEPart) error {
+		content, err := io.ReadAll(part.Reader)
+		if err != nil {
+			return err
+		}
+		bodies = append(bodies, string(content))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WalkParts returned error: %v", err)
+	}
+
+	if len(bodies) != 1 || strings.TrimSpace(bodies[0]) != "café" {
+		t.Errorf("expected decoded body %q, got %v", "café", bodies)
+	}
+}
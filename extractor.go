@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/fmurodov/pdf-from-eml/emlparse"
+)
+
+// Extractor saves the content of a MIME part it has been matched against
+// into sink and returns the result.
+type Extractor func(part *emlparse.MIMEPart, emlFilePath string, sink AttachmentSink) (SaveResult, error)
+
+type extractorEntry struct {
+	pattern   string
+	extractor Extractor
+}
+
+var (
+	registryMu sync.Mutex
+	registry   []extractorEntry
+)
+
+// RegisterExtractor registers extractor to run on any MIME part whose
+// Content-Type matches pattern. pattern is a glob understood by path.Match,
+// e.g. "application/pdf" or "image/*". Several extractors may be
+// registered for overlapping patterns; all matching extractors run for a
+// given part, in registration order.
+func RegisterExtractor(pattern string, extractor Extractor) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry = append(registry, extractorEntry{pattern, extractor})
+}
+
+// extractorsFor returns the extractors registered for contentType, in
+// registration order.
+func extractorsFor(contentType string) []Extractor {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	var matched []Extractor
+	for _, entry := range registry {
+		if ok, err := path.Match(entry.pattern, contentType); err == nil && ok {
+			matched = append(matched, entry.extractor)
+		}
+	}
+	return matched
+}
+
+// saveAttachment is the generic Extractor registered for every pattern in
+// -types: it decodes the part's filename and hands its already
+// transfer-decoded content to sink under a unique name.
+func saveAttachment(part *emlparse.MIMEPart, emlFilePath string, sink AttachmentSink) (SaveResult, error) {
+	filename := part.DispParams["filename"] // Try to get filename from Content-Disposition first
+	if filename == "" {
+		// Fallback: Try to get filename from 'name' parameter in Content-Type
+		filename = part.TypeParams["name"]
+	}
+
+	// Decode the filename if it uses MIME "Encoded-Word Syntax" (e.g., =?UTF-8?B?...)
+	filename = emlparse.DecodeHeaderWord(filename)
+
+	if filename == "" {
+		// If no filename is provided or after decoding it's empty, generate a fallback name
+		filename = fmt.Sprintf("unnamed_%s%s", strings.ReplaceAll(filepath.Base(emlFilePath), ".", "_"), defaultExtension(part.ContentType))
+	}
+
+	return sink.Save(filename, part)
+}
+
+// defaultExtension returns a best-effort file extension derived from a
+// content type, used when a part carries no filename at all, e.g.
+// "application/pdf" becomes ".pdf".
+func defaultExtension(contentType string) string {
+	if _, sub, ok := strings.Cut(contentType, "/"); ok && sub != "" {
+		return "." + sub
+	}
+	return ".bin"
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/fmurodov/pdf-from-eml/emlparse"
+)
+
+func TestExtractorsForGlobMatching(t *testing.T) {
+	registryMu.Lock()
+	savedRegistry := registry
+	registry = nil
+	registryMu.Unlock()
+	defer func() {
+		registryMu.Lock()
+		registry = savedRegistry
+		registryMu.Unlock()
+	}()
+
+	var pdfCalls, imgCalls int
+	RegisterExtractor("application/pdf", func(part *emlparse.MIMEPart, emlFilePath string, sink AttachmentSink) (SaveResult, error) {
+		pdfCalls++
+		return SaveResult{}, nil
+	})
+	RegisterExtractor("image/*", func(part *emlparse.MIMEPart, emlFilePath string, sink AttachmentSink) (SaveResult, error) {
+		imgCalls++
+		return SaveResult{}, nil
+	})
+
+	if got := len(extractorsFor("application/pdf")); got != 1 {
+		t.Errorf("expected 1 extractor for application/pdf, got %d", got)
+	}
+	if got := len(extractorsFor("image/jpeg")); got != 1 {
+		t.Errorf("expected 1 extractor for image/jpeg, got %d", got)
+	}
+	if got := len(extractorsFor("image/png")); got != 1 {
+		t.Errorf("expected 1 extractor for image/png, got %d", got)
+	}
+	if got := len(extractorsFor("application/zip")); got != 0 {
+		t.Errorf("expected 0 extractors for application/zip, got %d", got)
+	}
+}
+
+func TestDefaultExtension(t *testing.T) {
+	testCases := []struct {
+		contentType string
+		expected    string
+	}{
+		{"application/pdf", ".pdf"},
+		{"image/jpeg", ".jpeg"},
+		{"", ".bin"},
+		{"malformed", ".bin"},
+	}
+
+	for _, tc := range testCases {
+		if got := defaultExtension(tc.contentType); got != tc.expected {
+			t.Errorf("defaultExtension(%q) = %q, want %q", tc.contentType, got, tc.expected)
+		}
+	}
+}
+
+func TestRegisterRequestedExtractors(t *testing.T) {
+	registryMu.Lock()
+	savedRegistry := registry
+	registry = nil
+	registryMu.Unlock()
+	defer func() {
+		registryMu.Lock()
+		registry = savedRegistry
+		registryMu.Unlock()
+	}()
+
+	registerRequestedExtractors(" application/pdf , image/jpeg ,, ")
+
+	if got := len(extractorsFor("application/pdf")); got != 1 {
+		t.Errorf("expected 1 extractor for application/pdf, got %d", got)
+	}
+	if got := len(extractorsFor("image/jpeg")); got != 1 {
+		t.Errorf("expected 1 extractor for image/jpeg, got %d", got)
+	}
+	if got := len(extractorsFor("application/zip")); got != 0 {
+		t.Errorf("expected 0 extractors for application/zip, got %d", got)
+	}
+}
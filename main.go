@@ -1,35 +1,48 @@
 package main
 
 import (
-	"encoding/base64" // For decoding base64 content
-	"flag"            // For parsing command-line arguments
-	"fmt"             // For formatted I/O (printing messages)
-	"io"              // For I/O primitives (like copying streams)
-	"log"             // For logging errors and messages
-	"mime"            // For parsing MIME media types and parameters, and WordDecoder
-	"mime/multipart"  // For parsing multipart email bodies
-	"net/mail"        // For parsing email messages
-	"os"              // For operating system interactions (file system)
-	"path/filepath"   // For manipulating file paths
-	"strings"         // For string manipulation (e.g., checking file extensions)
-
-	// Import character set encodings from golang.org/x/text/encoding
-
-	"golang.org/x/text/encoding/htmlindex" // For looking up charsets by name
-	"golang.org/x/text/transform"          // For transforming data streams
+	"flag"          // For parsing command-line arguments
+	"fmt"           // For formatted I/O (printing messages)
+	"io"            // For the shared ExtractFromReader core
+	"log"           // For logging errors and messages
+	"net/mail"      // For parsing email messages
+	"os"            // For operating system interactions (file system)
+	"path/filepath" // For manipulating file paths
+	"runtime"       // For NumCPU, to size the default worker pool
+	"strings"       // For string manipulation (e.g., checking file extensions)
+	"sync"          // For WaitGroup, to wait on worker goroutines
+	"sync/atomic"   // For the extracted-attachment counter shared across workers
+
+	"github.com/fmurodov/pdf-from-eml/emlparse"
 )
 
 func main() {
 	// Define command-line flags for input and output directories
 	inputDir := flag.String("input", "", "Path to the input folder containing .eml files")
 	outputDir := flag.String("output", "extracted_pdfs", "Path to the output folder for extracted PDFs")
+	typesFlag := flag.String("types", "application/pdf", "Comma-separated list of Content-Types to extract; glob patterns like \"image/*\" are allowed")
+	workers := flag.Int("workers", runtime.NumCPU(), "Number of .eml files to process concurrently")
+	manifestFlag := flag.String("manifest", "", "Path to a combined JSON manifest file; when empty, writes one <eml>.manifest.json sidecar per processed EML")
+	stdinFlag := flag.Bool("stdin", false, "Read a single EML message from stdin instead of scanning -input")
+	stdoutFlag := flag.Bool("stdout", false, "With -stdin, stream extracted attachments to stdout instead of writing files under -output")
+	formatFlag := flag.String("format", "multipart", "Stdout stream format with -stdin -stdout: \"multipart\" or \"tar\"")
 	flag.Parse() // Parse the command-line arguments
 
+	if *stdinFlag {
+		registerRequestedExtractors(*typesFlag)
+		if err := runStdinMode(*outputDir, *manifestFlag, *stdoutFlag, *formatFlag); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		return
+	}
+
 	// Validate that the input directory is provided
 	if *inputDir == "" {
 		log.Fatal("Error: Input directory is required. Use -input flag.")
 	}
 
+	registerRequestedExtractors(*typesFlag)
+
 	// Check if the input directory exists
 	if _, err := os.Stat(*inputDir); os.IsNotExist(err) {
 		log.Fatalf("Error: Input directory '%s' does not exist.", *inputDir)
@@ -41,11 +54,37 @@ func main() {
 		log.Fatalf("Error creating output directory '%s': %v", *outputDir, err)
 	}
 
-	fmt.Printf("Scanning '%s' for .eml files and extracting PDFs to '%s'\n", *inputDir, *outputDir)
+	numWorkers := *workers
+	if numWorkers < 1 {
+		numWorkers = 1
+	}
+
+	fmt.Printf("Scanning '%s' for .eml files and extracting %s to '%s' with %d worker(s)\n",
+		*inputDir, *typesFlag, *outputDir, numWorkers)
+
+	var extractedCount int64 // Counter for extracted attachments, shared across workers
+	manifestWriter := NewManifestWriter(*manifestFlag)
+
+	paths := make(chan string)
 
-	extractedCount := 0 // Counter for extracted PDF files
+	// Dispatch discovered .eml paths to a bounded pool of worker goroutines.
+	var wg sync.WaitGroup
+	for i := 0; i < numWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range paths {
+				log.Printf("Processing EML file: %s\n", path)
+				count, err := extractPdfsFromEml(path, *outputDir, manifestWriter)
+				if err != nil {
+					log.Printf("Error processing %s: %v\n", path, err)
+				}
+				atomic.AddInt64(&extractedCount, int64(count))
+			}
+		}()
+	}
 
-	// Walk through the input directory recursively
+	// Walk through the input directory recursively, feeding .eml paths to the workers
 	err := filepath.Walk(*inputDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			log.Printf("Error accessing path %q: %v\n", path, err)
@@ -57,284 +96,141 @@ func main() {
 
 		// Check if the file has a .eml extension (case-insensitive)
 		if strings.ToLower(filepath.Ext(path)) == ".eml" {
-			log.Printf("Processing EML file: %s\n", path)
-			// Call the function to extract PDFs from the current EML file
-			count, err := extractPdfsFromEml(path, *outputDir)
-			if err != nil {
-				log.Printf("Error processing %s: %v\n", path, err)
-			}
-			extractedCount += count // Accumulate the count of extracted PDFs
+			paths <- path
 		}
 		return nil // Continue walking
 	})
+	close(paths)
+	wg.Wait()
 
 	if err != nil {
 		log.Fatalf("Error walking the directory: %v\n", err)
 	}
 
-	fmt.Printf("Finished! Extracted %d PDF(s).\n", extractedCount)
-}
-
-// createCharsetReader returns a CharsetReader function suitable for mime.WordDecoder.
-// This function maps charsets to their corresponding decoders from golang.org/x/text/encoding.
-func createCharsetReader(charset string, input io.Reader) (io.Reader, error) {
-	// Look up the encoding by its name (charset string).
-	enc, err := htmlindex.Get(charset)
-	if err != nil {
-		return nil, fmt.Errorf("unhandled charset %q: %w", charset, err)
+	if err := manifestWriter.Close(); err != nil {
+		log.Fatalf("Error writing manifest: %v\n", err)
 	}
-	return transform.NewReader(input, enc.NewDecoder()), nil
+
+	fmt.Printf("Finished! Extracted %d attachment(s).\n", extractedCount)
 }
 
-// extractPdfsFromEml parses an EML file and extracts PDF attachments.
-// It returns the number of PDFs extracted from this file and any error encountered.
-func extractPdfsFromEml(emlFilePath, outputDir string) (int, error) {
+// extractPdfsFromEml parses an EML file and extracts attachments matching
+// the registered extractors (see RegisterExtractor) from anywhere in its
+// MIME tree, no matter how deeply it is nested inside multipart/mixed,
+// multipart/alternative, multipart/related, or multipart/signed containers.
+// It records the message and its attachments through manifestWriter, and
+// returns the number of attachments extracted from this file and any
+// error encountered.
+func extractPdfsFromEml(emlFilePath, outputDir string, manifestWriter *ManifestWriter) (int, error) {
 	file, err := os.Open(emlFilePath)
 	if err != nil {
 		return 0, fmt.Errorf("could not open EML file %s: %w", emlFilePath, err)
 	}
 	defer file.Close() // Ensure the file is closed when the function exits
 
-	msg, err := mail.ReadMessage(file)
-	if err != nil {
-		return 0, fmt.Errorf("could not read EML message from %s: %w", emlFilePath, err)
-	}
+	return ExtractFromReader(file, emlFilePath, dirSink{outputDir: outputDir}, manifestWriter)
+}
 
-	// Parse the Content-Type header of the main message
-	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+// ExtractFromReader is the core extraction pipeline shared by every mode:
+// it reads one EML message from r, walks its MIME tree, runs every
+// registered Extractor matching against each attachment part, and writes
+// the result to sink. sourceName is recorded in the manifest as the
+// message's source path; it need not be a real file (e.g. "-" for
+// stdin). It returns the number of attachments extracted and any error
+// encountered.
+func ExtractFromReader(r io.Reader, sourceName string, sink AttachmentSink, manifestWriter *ManifestWriter) (int, error) {
+	msg, err := mail.ReadMessage(r)
 	if err != nil {
-		// Log a warning if Content-Type is unparseable but don't fail,
-		// as it might be a simple text email or a malformed header.
-		// Try to process the main body in case it's a direct PDF.
-		log.Printf("Warning: Could not parse Content-Type for %s: %v\n", emlFilePath, err)
-		return processBody(msg.Body, msg.Header, emlFilePath, outputDir)
+		return 0, fmt.Errorf("could not read EML message from %s: %w", sourceName, err)
 	}
 
-	// Check if the message is a multipart message (e.g., multipart/mixed, multipart/alternative)
-	if strings.HasPrefix(mediaType, "multipart/") {
-		boundary := params["boundary"] // Get the boundary string for multipart messages
-		if boundary == "" {
-			return 0, fmt.Errorf("multipart message without boundary in %s", emlFilePath)
-		}
-
-		// Create a new multipart reader from the message body
-		mr := multipart.NewReader(msg.Body, boundary)
-		extractedInEml := 0 // Counter for PDFs extracted from this specific EML file
+	manifest := newEMLManifest(sourceName, msg.Header)
 
-		// Iterate through each part of the multipart message
-		for {
-			p, err := mr.NextPart() // Get the next part
-			if err == io.EOF {
-				break // No more parts
-			}
-			if err != nil {
-				return extractedInEml, fmt.Errorf("error reading multipart part from %s: %w", emlFilePath, err)
-			}
-
-			// Process each part to check for PDF attachments
-			count, err := processPart(p, emlFilePath, outputDir)
-			if err != nil {
-				// Log warnings for individual part errors but continue processing other parts
-				log.Printf("Warning: Error processing part in %s: %v\n", emlFilePath, err)
-			}
-			extractedInEml += count // Add to the count for this EML file
+	extracted := 0
+	err = emlparse.WalkParts(msg, func(part *emlparse.MIMEPart) error {
+		count, err := processPart(part, sourceName, sink, manifest)
+		if err != nil {
+			// Log warnings for individual part errors but continue processing other parts
+			log.Printf("Warning: Error processing part in %s: %v\n", sourceName, err)
 		}
-		return extractedInEml, nil
-	} else {
-		// If it's not a multipart message, try to process the main body itself
-		// This handles cases where the entire email content might be a PDF.
-		return processBody(msg.Body, msg.Header, emlFilePath, outputDir)
-	}
-}
-
-// processPart checks a multipart.Part for PDF attachments and saves them.
-// It returns 1 if a PDF was extracted, 0 otherwise, and an error if saving failed.
-func processPart(p *multipart.Part, emlFilePath, outputDir string) (int, error) {
-	// Parse Content-Type header of the part
-	contentType, contentTypeParams, err := mime.ParseMediaType(p.Header.Get("Content-Type"))
+		extracted += count
+		return nil
+	})
 	if err != nil {
-		return 0, fmt.Errorf("could not parse Content-Type for part: %w", err)
+		return extracted, fmt.Errorf("error walking MIME parts of %s: %w", sourceName, err)
 	}
 
-	// Parse Content-Disposition header of the part
-	disposition, dispParams, err := mime.ParseMediaType(p.Header.Get("Content-Disposition"))
-	if err != nil {
-		// Log a warning if Content-Disposition is unparseable but continue processing.
-		// We will try to derive filename from Content-Type if disposition is problematic.
-		log.Printf("Warning: Could not parse Content-Disposition for part in %s (header: '%s'): %v\n",
-			emlFilePath, p.Header.Get("Content-Disposition"), err)
-		disposition = "" // Clear disposition if parsing failed, so logic below can use it as "not attachment"
+	if err := manifestWriter.Write(manifest); err != nil {
+		log.Printf("Warning: could not write manifest for %s: %v\n", sourceName, err)
 	}
 
-	// Check if it's a PDF. We consider it an attachment if Content-Disposition is "attachment"
-	// OR if Content-Type is "application/pdf" AND it has a 'name' parameter (common for inline attachments).
-	isPdfAttachment := contentType == "application/pdf" &&
-		(disposition == "attachment" || (disposition == "" && contentTypeParams["name"] != ""))
-
-	if isPdfAttachment {
-		filename := dispParams["filename"] // Try to get filename from Content-Disposition first
-
-		if filename == "" {
-			// Fallback: Try to get filename from 'name' parameter in Content-Type
-			filename = contentTypeParams["name"]
-		}
-
-		// Decode the filename if it uses MIME "Encoded-Word Syntax" (e.g., =?UTF-8?B?...)
-		// Trim whitespace before decoding to handle potential hidden characters.
-		filenameToDecode := strings.TrimSpace(filename)
-
-		// Create a new WordDecoder instance and set its CharsetReader.
-		decoder := &mime.WordDecoder{
-			CharsetReader: createCharsetReader,
-		}
-
-		decodedFilename, err := decoder.DecodeHeader(filenameToDecode)
-		if err == nil { // If decoding is successful, use the decoded filename
-			filename = decodedFilename
-		} else {
-			// Log the specific error from DecodeHeader for better debugging
-			log.Printf("Warning: mime.WordDecoder failed to decode filename '%s' from part in %s: %v. Using original filename.\n",
-				filenameToDecode, emlFilePath, err)
-			// The 'filename' variable will retain its original (encoded) value if decoding fails.
-		}
-
-		if filename == "" {
-			// If no filename is provided or after decoding it's empty, generate a fallback name
-			filename = fmt.Sprintf("unnamed_pdf_%s.pdf", strings.ReplaceAll(filepath.Base(emlFilePath), ".", "_"))
-			log.Printf("Warning: PDF attachment in %s has no filename, using '%s'\n", emlFilePath, filename)
-		}
-
-		// Determine the appropriate reader for the part's content
-		var partReader io.Reader = p
-		transferEncoding := p.Header.Get("Content-Transfer-Encoding")
-		if strings.ToLower(transferEncoding) == "base64" {
-			// If content is base64 encoded, decode it on the fly
-			partReader = base64.NewDecoder(base64.StdEncoding, p)
-		}
-
-		// Construct the full path for the output PDF file
-		outputFilePath := filepath.Join(outputDir, filename)
-		// Ensure the filename is unique to avoid overwriting existing files
-		uniqueFilePath := getUniqueFilename(outputFilePath)
-
-		// Create the output file
-		outFile, err := os.Create(uniqueFilePath)
-		if err != nil {
-			return 0, fmt.Errorf("could not create output file %s: %w", uniqueFilePath, err)
-		}
-		defer outFile.Close() // Close the output file when done
-
-		// Copy the content from the part reader to the output file
-		bytesWritten, err := io.Copy(outFile, partReader)
-		if err != nil {
-			return 0, fmt.Errorf("could not write PDF content to %s: %w", uniqueFilePath, err)
-		}
-
-		log.Printf("Extracted PDF: %s (%d bytes)\n", uniqueFilePath, bytesWritten)
-		return 1, nil // Return 1 indicating one PDF was extracted
-	}
-	return 0, nil // Not a PDF attachment, return 0
+	return extracted, nil
 }
 
-// processBody attempts to process a non-multipart message body for a PDF.
-// This is used if the entire EML's body is a PDF, not as a multipart attachment.
-func processBody(body io.Reader, headers mail.Header, emlFilePath, outputDir string) (int, error) {
-	// Parse Content-Type header of the main message body
-	contentType, contentTypeParams, err := mime.ParseMediaType(headers.Get("Content-Type"))
-	if err != nil {
-		return 0, nil // No content type or unparseable, assume not a PDF
+// processPart runs every registered Extractor that matches the part's
+// Content-Type against it, recording a manifest entry for each successful
+// extraction. It returns the number of extractors that successfully wrote
+// output for this part, and the first error encountered.
+func processPart(part *emlparse.MIMEPart, emlFilePath string, sink AttachmentSink, manifest *EMLManifest) (int, error) {
+	if !emlparse.IsAttachment(part.Header) {
+		return 0, nil
 	}
 
-	// Parse Content-Disposition header of the main message body (might be empty)
-	disposition, dispParams, err := mime.ParseMediaType(headers.Get("Content-Disposition"))
-	if err != nil {
-		log.Printf("Warning: Could not parse Content-Disposition for main body in %s (header: '%s'): %v\n",
-			emlFilePath, headers.Get("Content-Disposition"), err)
-		disposition = "" // Clear disposition if parsing failed
+	extractors := extractorsFor(part.ContentType)
+	if len(extractors) == 0 {
+		return 0, nil // No registered extractor wants this Content-Type
 	}
 
-	// Check if the main body is a PDF and (is an attachment or has no disposition specified
-	// but has a 'name' parameter in Content-Type).
-	isPdfAttachment := contentType == "application/pdf" &&
-		(disposition == "attachment" || (disposition == "" && contentTypeParams["name"] != ""))
-
-	if isPdfAttachment {
-		filename := dispParams["filename"] // Try from Content-Disposition first
-
-		if filename == "" {
-			// Fallback: Try to get filename from 'name' parameter in Content-Type
-			filename = contentTypeParams["name"]
-		}
-
-		// Decode the filename if it uses MIME "Encoded-Word Syntax"
-		// Trim whitespace before decoding.
-		filenameToDecode := strings.TrimSpace(filename)
-
-		// Create a new WordDecoder instance and set its CharsetReader.
-		decoder := &mime.WordDecoder{
-			CharsetReader: createCharsetReader,
-		}
-
-		decodedFilename, err := decoder.DecodeHeader(filenameToDecode)
-		if err == nil {
-			filename = decodedFilename
-		} else {
-			// Log the specific error from DecodeHeader for better debugging
-			log.Printf("Warning: mime.WordDecoder failed to decode filename '%s' from main body in %s: %v. Using original filename.\n",
-				filenameToDecode, emlFilePath, err)
-			// The 'filename' variable will retain its original (encoded) value if decoding fails.
-		}
-
-		if filename == "" {
-			// Generate a fallback name for the PDF from the main body
-			filename = fmt.Sprintf("unnamed_body_pdf_%s.pdf", strings.ReplaceAll(filepath.Base(emlFilePath), ".", "_"))
-			log.Printf("Warning: Main body PDF in %s has no filename, using '%s'\n", emlFilePath, filename)
-		}
-
-		// Determine the appropriate reader for the body's content
-		var bodyReader io.Reader = body
-		transferEncoding := headers.Get("Content-Transfer-Encoding")
-		if strings.ToLower(transferEncoding) == "base64" {
-			bodyReader = base64.NewDecoder(base64.StdEncoding, body)
-		}
-
-		// Construct the full path for the output PDF file
-		outputFilePath := filepath.Join(outputDir, filename)
-		// Ensure the filename is unique
-		uniqueFilePath := getUniqueFilename(outputFilePath)
-
-		// Create the output file
-		outFile, err := os.Create(uniqueFilePath)
+	extracted := 0
+	for _, extract := range extractors {
+		result, err := extract(part, emlFilePath, sink)
 		if err != nil {
-			return 0, fmt.Errorf("could not create output file %s: %w", uniqueFilePath, err)
+			return extracted, fmt.Errorf("extracting %s part: %w", part.ContentType, err)
 		}
-		defer outFile.Close()
+		log.Printf("Extracted %s: %s\n", part.ContentType, result.Location)
+		extracted++
 
-		// Copy the content from the body reader to the output file
-		bytesWritten, err := io.Copy(outFile, bodyReader)
-		if err != nil {
-			return 0, fmt.Errorf("could not write PDF content to %s: %w", uniqueFilePath, err)
-		}
+		manifest.Attachments = append(manifest.Attachments, recordAttachment(part, emlFilePath, result))
+	}
+	return extracted, nil
+}
 
-		log.Printf("Extracted PDF from main body: %s (%d bytes)\n", uniqueFilePath, bytesWritten)
-		return 1, nil // Return 1 indicating one PDF was extracted
+// registerRequestedExtractors registers the generic saveAttachment
+// Extractor for every Content-Type pattern in a comma-separated -types
+// flag value.
+func registerRequestedExtractors(typesFlag string) {
+	for _, pattern := range strings.Split(typesFlag, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		RegisterExtractor(pattern, saveAttachment)
 	}
-	return 0, nil // Not a PDF in the main body, return 0
 }
 
-// getUniqueFilename appends a counter to the filename if a file with the same name already exists.
-func getUniqueFilename(filePath string) string {
+// createUniqueFile atomically reserves a unique path derived from
+// filePath and creates it, returning the open file alongside the path it
+// was created at. It retries with an incrementing counter suffix (e.g.
+// "file_1.pdf") whenever os.OpenFile reports the candidate already
+// exists, so the reservation itself - not a prior os.Stat - is what
+// decides uniqueness. That makes it safe across concurrent workers in
+// this process and across separate invocations of this program sharing
+// an output directory, unlike a stat-then-create sequence which leaves a
+// window between the check and the create. Callers are responsible for
+// closing the returned file.
+func createUniqueFile(filePath string) (*os.File, string, error) {
 	ext := filepath.Ext(filePath)             // Get file extension (e.g., ".pdf")
 	base := filePath[:len(filePath)-len(ext)] // Get base name without extension
-	counter := 1                              // Start counter for uniqueness
 
-	for {
-		_, err := os.Stat(filePath) // Check if the file exists
-		if os.IsNotExist(err) {
-			return filePath // File does not exist, so the current path is unique
+	for counter := 1; ; counter++ {
+		outFile, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			return outFile, filePath, nil
+		}
+		if !os.IsExist(err) {
+			return nil, "", err
 		}
-		// File exists, construct a new path with a counter (e.g., "file_1.pdf")
+		// Candidate already exists (reserved by another run or process), try the next suffix.
 		filePath = fmt.Sprintf("%s_%d%s", base, counter, ext)
-		counter++ // Increment counter for the next attempt
 	}
 }
@@ -4,10 +4,11 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 )
 
-func TestGetUniqueFilename(t *testing.T) {
+func TestCreateUniqueFile(t *testing.T) {
 	// Create a temporary directory for testing
 	tempDir, err := os.MkdirTemp("", "test_unique_filename")
 	if err != nil {
@@ -15,59 +16,83 @@ func TestGetUniqueFilename(t *testing.T) {
 	}
 	defer os.RemoveAll(tempDir)
 
-	// Test case 1: File doesn't exist, should return original path
+	// Test case 1: File doesn't exist, should create and return the original path
 	originalPath := filepath.Join(tempDir, "test.pdf")
-	uniquePath := getUniqueFilename(originalPath)
+	f, uniquePath, err := createUniqueFile(originalPath)
+	if err != nil {
+		t.Fatalf("createUniqueFile: %v", err)
+	}
+	f.Close()
 	if uniquePath != originalPath {
 		t.Errorf("Expected %s, got %s", originalPath, uniquePath)
 	}
 
-	// Test case 2: File exists, should return path with counter
-	// Create the original file
-	file, err := os.Create(originalPath)
+	// Test case 2: Path already reserved, should fall back to a counter suffix
+	expectedPath := filepath.Join(tempDir, "test_1.pdf")
+	f, uniquePath, err = createUniqueFile(originalPath)
 	if err != nil {
-		t.Fatalf("Failed to create test file: %v", err)
+		t.Fatalf("createUniqueFile: %v", err)
 	}
-	file.Close()
-
-	uniquePath = getUniqueFilename(originalPath)
-	expectedPath := filepath.Join(tempDir, "test_1.pdf")
+	f.Close()
 	if uniquePath != expectedPath {
 		t.Errorf("Expected %s, got %s", expectedPath, uniquePath)
 	}
 
-	// Test case 3: Multiple files exist
-	file2, err := os.Create(expectedPath)
+	// Test case 3: Multiple paths already reserved
+	expectedPath2 := filepath.Join(tempDir, "test_2.pdf")
+	f, uniquePath, err = createUniqueFile(originalPath)
 	if err != nil {
-		t.Fatalf("Failed to create test file 2: %v", err)
+		t.Fatalf("createUniqueFile: %v", err)
 	}
-	file2.Close()
-
-	uniquePath = getUniqueFilename(originalPath)
-	expectedPath2 := filepath.Join(tempDir, "test_2.pdf")
+	f.Close()
 	if uniquePath != expectedPath2 {
 		t.Errorf("Expected %s, got %s", expectedPath2, uniquePath)
 	}
 }
 
-func TestCreateCharsetReader(t *testing.T) {
-	testString := "Hello, World!"
-	reader := strings.NewReader(testString)
-
-	// Test with UTF-8 charset
-	charsetReader, err := createCharsetReader("utf-8", reader)
+func TestCreateUniqueFileConcurrent(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "test_create_unique_file")
 	if err != nil {
-		t.Errorf("Expected no error for utf-8 charset, got: %v", err)
+		t.Fatalf("Failed to create temp dir: %v", err)
 	}
-	if charsetReader == nil {
-		t.Error("Expected non-nil reader for utf-8 charset")
+	defer os.RemoveAll(tempDir)
+
+	targetPath := filepath.Join(tempDir, "test.pdf")
+
+	const goroutines = 20
+	paths := make([]string, goroutines)
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			outFile, uniquePath, err := createUniqueFile(targetPath)
+			if err != nil {
+				t.Errorf("createUniqueFile: %v", err)
+				return
+			}
+			outFile.Close()
+			paths[i] = uniquePath
+		}()
 	}
+	wg.Wait()
 
-	// Test with unsupported charset
-	reader2 := strings.NewReader(testString)
-	_, err = createCharsetReader("invalid-charset", reader2)
-	if err == nil {
-		t.Error("Expected error for invalid charset, got nil")
+	seen := make(map[string]bool, goroutines)
+	for _, p := range paths {
+		if p == "" {
+			continue
+		}
+		if seen[p] {
+			t.Errorf("path %s was reserved by more than one goroutine", p)
+		}
+		seen[p] = true
+		if _, err := os.Stat(p); err != nil {
+			t.Errorf("expected %s to exist: %v", p, err)
+		}
+	}
+	if len(seen) != goroutines {
+		t.Errorf("expected %d unique paths, got %d", goroutines, len(seen))
 	}
 }
 
@@ -107,57 +132,7 @@ func TestFileExtensionCheck(t *testing.T) {
 	}
 }
 
-func TestProcessBodyLogic(t *testing.T) {
-	// Test the logic for determining if content is a PDF attachment
-	testCases := []struct {
-		contentType     string
-		disposition     string
-		contentTypeName string
-		expected        bool
-		description     string
-	}{
-		{
-			contentType:     "application/pdf",
-			disposition:     "attachment",
-			contentTypeName: "",
-			expected:        true,
-			description:     "PDF with attachment disposition",
-		},
-		{
-			contentType:     "application/pdf",
-			disposition:     "",
-			contentTypeName: "document.pdf",
-			expected:        true,
-			description:     "PDF with name parameter",
-		},
-		{
-			contentType:     "text/plain",
-			disposition:     "attachment",
-			contentTypeName: "",
-			expected:        false,
-			description:     "Text file with attachment disposition",
-		},
-		{
-			contentType:     "application/pdf",
-			disposition:     "inline",
-			contentTypeName: "",
-			expected:        false,
-			description:     "PDF with inline disposition and no name",
-		},
-	}
-
-	for _, tc := range testCases {
-		// Simulate the logic from processPart/processBody functions
-		isPdfAttachment := tc.contentType == "application/pdf" &&
-			(tc.disposition == "attachment" || (tc.disposition == "" && tc.contentTypeName != ""))
-
-		if isPdfAttachment != tc.expected {
-			t.Errorf("Test case '%s': expected %v, got %v", tc.description, tc.expected, isPdfAttachment)
-		}
-	}
-}
-
-func BenchmarkGetUniqueFilename(b *testing.B) {
+func BenchmarkCreateUniqueFile(b *testing.B) {
 	tempDir, err := os.MkdirTemp("", "bench_unique_filename")
 	if err != nil {
 		b.Fatalf("Failed to create temp dir: %v", err)
@@ -168,6 +143,10 @@ func BenchmarkGetUniqueFilename(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		getUniqueFilename(testPath)
+		f, _, err := createUniqueFile(testPath)
+		if err != nil {
+			b.Fatalf("createUniqueFile: %v", err)
+		}
+		f.Close()
 	}
 }
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/mail"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/fmurodov/pdf-from-eml/emlparse"
+)
+
+// AttachmentRecord describes one attachment extracted from an EML file, in
+// enough detail for a downstream indexing or dedup pipeline to consume
+// without re-parsing the EML.
+type AttachmentRecord struct {
+	FilenameRaw      string `json:"filename_raw"`                // As declared in the headers, still RFC 2047 encoded if it was
+	Filename         string `json:"filename"`                    // RFC 2047-decoded filename
+	ContentType      string `json:"content_type"`                // Declared Content-Type
+	TransferEncoding string `json:"transfer_encoding,omitempty"` // Declared Content-Transfer-Encoding
+	SizeBytes        int64  `json:"size_bytes"`                  // Size of the decoded content on disk
+	SHA256           string `json:"sha256"`                      // SHA-256 of the decoded content
+	SourcePath       string `json:"source_path"`                 // Path to the EML this attachment came from
+	OutputPath       string `json:"output_path"`                 // Path the attachment was written to
+}
+
+// EMLManifest is the message-level metadata and attachment list recorded
+// for one processed EML file.
+type EMLManifest struct {
+	SourcePath  string             `json:"source_path"`
+	MessageID   string             `json:"message_id,omitempty"`
+	From        string             `json:"from,omitempty"`
+	To          string             `json:"to,omitempty"`
+	Subject     string             `json:"subject,omitempty"`
+	Date        *time.Time         `json:"date,omitempty"`
+	Attachments []AttachmentRecord `json:"attachments"`
+}
+
+// newEMLManifest builds the message-level portion of a manifest from an
+// EML's headers.
+func newEMLManifest(emlFilePath string, header mail.Header) *EMLManifest {
+	m := &EMLManifest{
+		SourcePath:  emlFilePath,
+		MessageID:   header.Get("Message-Id"),
+		From:        header.Get("From"),
+		To:          header.Get("To"),
+		Subject:     emlparse.DecodeHeaderWord(header.Get("Subject")),
+		Attachments: []AttachmentRecord{},
+	}
+	if date, err := header.Date(); err == nil {
+		m.Date = &date
+	}
+	return m
+}
+
+// recordAttachment builds an AttachmentRecord for a saved attachment from
+// the SaveResult its sink returned, with no need to re-read the content.
+func recordAttachment(part *emlparse.MIMEPart, emlFilePath string, result SaveResult) AttachmentRecord {
+	rawFilename := part.DispParams["filename"]
+	if rawFilename == "" {
+		rawFilename = part.TypeParams["name"]
+	}
+
+	return AttachmentRecord{
+		FilenameRaw:      rawFilename,
+		Filename:         emlparse.DecodeHeaderWord(rawFilename),
+		ContentType:      part.ContentType,
+		TransferEncoding: part.Header.Get("Content-Transfer-Encoding"),
+		SizeBytes:        result.SizeBytes,
+		SHA256:           result.SHA256,
+		SourcePath:       emlFilePath,
+		OutputPath:       result.Location,
+	}
+}
+
+// ManifestWriter records one EMLManifest per processed EML file. With no
+// combined path configured it writes a "<eml path>.manifest.json" sidecar
+// next to each EML as soon as it is processed; with a combined path it
+// buffers manifests and writes them as a single JSON array on Close.
+type ManifestWriter struct {
+	combinedPath string
+
+	mu       sync.Mutex
+	combined []*EMLManifest
+}
+
+// NewManifestWriter returns a ManifestWriter. An empty combinedPath
+// selects per-EML sidecar files; a non-empty one selects the combined
+// single-file mode.
+func NewManifestWriter(combinedPath string) *ManifestWriter {
+	return &ManifestWriter{combinedPath: combinedPath}
+}
+
+// Write records m, either writing its sidecar immediately or buffering it
+// for the combined manifest written by Close.
+func (w *ManifestWriter) Write(m *EMLManifest) error {
+	if w.combinedPath == "" {
+		return writeJSONFile(m.SourcePath+".manifest.json", m)
+	}
+	w.mu.Lock()
+	w.combined = append(w.combined, m)
+	w.mu.Unlock()
+	return nil
+}
+
+// Close flushes the combined manifest file, if one was configured. It is
+// a no-op in per-EML sidecar mode.
+func (w *ManifestWriter) Close() error {
+	if w.combinedPath == "" {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return writeJSONFile(w.combinedPath, w.combined)
+}
+
+// writeJSONFile marshals v as indented JSON and writes it to path.
+func writeJSONFile(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not marshal manifest: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("could not write manifest %s: %w", path, err)
+	}
+	return nil
+}
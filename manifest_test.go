@@ -0,0 +1,148 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fmurodov/pdf-from-eml/emlparse"
+)
+
+func TestNewEMLManifest(t *testing.T) {
+	header := mail.Header{
+		"Message-Id": []string{"<abc123@example.com>"},
+		"From":       []string{"sender@example.com"},
+		"To":         []string{"recipient@example.com"},
+		"Subject":    []string{"=?UTF-8?B?UmVwb3J0?="},
+		"Date":       []string{"Mon, 02 Jan 2006 15:04:05 -0700"},
+	}
+
+	m := newEMLManifest("/mail/test.eml", header)
+
+	if m.SourcePath != "/mail/test.eml" {
+		t.Errorf("SourcePath = %q, want %q", m.SourcePath, "/mail/test.eml")
+	}
+	if m.MessageID != "<abc123@example.com>" {
+		t.Errorf("MessageID = %q", m.MessageID)
+	}
+	if m.Subject != "Report" {
+		t.Errorf("Subject = %q, want %q", m.Subject, "Report")
+	}
+	if m.Date == nil {
+		t.Fatal("expected Date to be parsed")
+	}
+	if m.Attachments == nil {
+		t.Error("expected Attachments to be initialized to an empty slice, not nil")
+	}
+}
+
+func TestRecordAttachment(t *testing.T) {
+	content := []byte("hello world")
+	sum := sha256.Sum256(content)
+	result := SaveResult{
+		Location:  "/out/doc.pdf",
+		SizeBytes: int64(len(content)),
+		SHA256:    hex.EncodeToString(sum[:]),
+	}
+
+	part := &emlparse.MIMEPart{
+		Header:      map[string][]string{"Content-Transfer-Encoding": {"base64"}},
+		ContentType: "application/pdf",
+		DispParams:  map[string]string{"filename": "=?UTF-8?B?ZG9jLnBkZg==?="},
+	}
+
+	record := recordAttachment(part, "/mail/test.eml", result)
+
+	if record.Filename != "doc.pdf" {
+		t.Errorf("Filename = %q, want %q", record.Filename, "doc.pdf")
+	}
+	if record.FilenameRaw != "=?UTF-8?B?ZG9jLnBkZg==?=" {
+		t.Errorf("FilenameRaw = %q", record.FilenameRaw)
+	}
+	if record.SizeBytes != int64(len(content)) {
+		t.Errorf("SizeBytes = %d, want %d", record.SizeBytes, len(content))
+	}
+	if record.SHA256 != hex.EncodeToString(sum[:]) {
+		t.Errorf("SHA256 = %q, want %q", record.SHA256, hex.EncodeToString(sum[:]))
+	}
+	if record.TransferEncoding != "base64" {
+		t.Errorf("TransferEncoding = %q, want %q", record.TransferEncoding, "base64")
+	}
+	if record.OutputPath != "/out/doc.pdf" {
+		t.Errorf("OutputPath = %q, want %q", record.OutputPath, "/out/doc.pdf")
+	}
+}
+
+func TestManifestWriterSidecarMode(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "test_manifest_sidecar")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	emlPath := filepath.Join(tempDir, "message.eml")
+	w := NewManifestWriter("")
+	m := &EMLManifest{SourcePath: emlPath, Subject: "hi", Attachments: []AttachmentRecord{}}
+
+	if err := w.Write(m); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	data, err := os.ReadFile(emlPath + ".manifest.json")
+	if err != nil {
+		t.Fatalf("expected sidecar file: %v", err)
+	}
+	var got EMLManifest
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("unmarshal sidecar: %v", err)
+	}
+	if got.Subject != "hi" {
+		t.Errorf("Subject = %q, want %q", got.Subject, "hi")
+	}
+}
+
+func TestManifestWriterCombinedMode(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "test_manifest_combined")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	combinedPath := filepath.Join(tempDir, "manifest.json")
+	w := NewManifestWriter(combinedPath)
+
+	if err := w.Write(&EMLManifest{SourcePath: "a.eml", Attachments: []AttachmentRecord{}}); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := w.Write(&EMLManifest{SourcePath: "b.eml", Attachments: []AttachmentRecord{}}); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	// Nothing should be written until Close.
+	if _, err := os.Stat(combinedPath); !os.IsNotExist(err) {
+		t.Fatalf("expected no combined manifest before Close, got err=%v", err)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	data, err := os.ReadFile(combinedPath)
+	if err != nil {
+		t.Fatalf("expected combined manifest file: %v", err)
+	}
+	var got []EMLManifest
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("unmarshal combined manifest: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected 2 manifests, got %d", len(got))
+	}
+}
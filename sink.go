@@ -0,0 +1,140 @@
+package main
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/textproto"
+	"path/filepath"
+	"sync"
+
+	"github.com/fmurodov/pdf-from-eml/emlparse"
+)
+
+// SaveResult describes where an attachment ended up and basic facts about
+// its bytes, so the caller can record a manifest entry without re-reading
+// the content afterward.
+type SaveResult struct {
+	Location  string // File path for disk sinks, or a synthetic identifier for streaming sinks
+	SizeBytes int64
+	SHA256    string
+}
+
+// AttachmentSink is the destination extracted attachments are written to:
+// a directory on disk, or a single stdout stream shared by every
+// attachment in the message.
+type AttachmentSink interface {
+	// Save writes part's decoded content under filename and returns
+	// where it ended up.
+	Save(filename string, part *emlparse.MIMEPart) (SaveResult, error)
+}
+
+// dirSink is the default AttachmentSink: one file per attachment under a
+// directory, named uniquely via createUniqueFile.
+type dirSink struct {
+	outputDir string
+}
+
+func (s dirSink) Save(filename string, part *emlparse.MIMEPart) (SaveResult, error) {
+	outputFilePath := filepath.Join(s.outputDir, filename)
+	outFile, uniqueFilePath, err := createUniqueFile(outputFilePath)
+	if err != nil {
+		return SaveResult{}, fmt.Errorf("could not create output file for %s: %w", outputFilePath, err)
+	}
+	defer outFile.Close()
+
+	hasher := sha256.New()
+	size, err := io.Copy(io.MultiWriter(outFile, hasher), part.Reader)
+	if err != nil {
+		return SaveResult{}, fmt.Errorf("could not write content to %s: %w", uniqueFilePath, err)
+	}
+	return SaveResult{Location: uniqueFilePath, SizeBytes: size, SHA256: hex.EncodeToString(hasher.Sum(nil))}, nil
+}
+
+// multipartSink streams every attachment it saves as one part of a single
+// multipart/mixed response written to an underlying io.Writer, for -stdin
+// -stdout mode. It is safe for concurrent use, though stdin mode only
+// ever processes one message at a time.
+type multipartSink struct {
+	mu sync.Mutex
+	mw *multipart.Writer
+}
+
+func newMultipartSink(w io.Writer) *multipartSink {
+	return &multipartSink{mw: multipart.NewWriter(w)}
+}
+
+// Boundary returns the multipart boundary, for callers that need to print
+// a Content-Type header describing the overall stream.
+func (s *multipartSink) Boundary() string {
+	return s.mw.Boundary()
+}
+
+func (s *multipartSink) Save(filename string, part *emlparse.MIMEPart) (SaveResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", part.ContentType)
+	header.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+
+	partWriter, err := s.mw.CreatePart(header)
+	if err != nil {
+		return SaveResult{}, fmt.Errorf("could not start multipart part for %s: %w", filename, err)
+	}
+
+	hasher := sha256.New()
+	size, err := io.Copy(io.MultiWriter(partWriter, hasher), part.Reader)
+	if err != nil {
+		return SaveResult{}, fmt.Errorf("could not stream %s: %w", filename, err)
+	}
+	return SaveResult{Location: "stdout:" + filename, SizeBytes: size, SHA256: hex.EncodeToString(hasher.Sum(nil))}, nil
+}
+
+func (s *multipartSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.mw.Close()
+}
+
+// tarSink streams every attachment it saves as one entry of a tar archive
+// written to an underlying io.Writer, for -stdin -stdout -format tar mode.
+// Because tar requires each entry's size up front, content is buffered in
+// memory before being written - acceptable for the single-message stdin
+// use case this targets.
+type tarSink struct {
+	mu sync.Mutex
+	tw *tar.Writer
+}
+
+func newTarSink(w io.Writer) *tarSink {
+	return &tarSink{tw: tar.NewWriter(w)}
+}
+
+func (s *tarSink) Save(filename string, part *emlparse.MIMEPart) (SaveResult, error) {
+	content, err := io.ReadAll(part.Reader)
+	if err != nil {
+		return SaveResult{}, fmt.Errorf("could not read content for %s: %w", filename, err)
+	}
+	sum := sha256.Sum256(content)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.tw.WriteHeader(&tar.Header{Name: filename, Mode: 0644, Size: int64(len(content))}); err != nil {
+		return SaveResult{}, fmt.Errorf("could not write tar header for %s: %w", filename, err)
+	}
+	if _, err := s.tw.Write(content); err != nil {
+		return SaveResult{}, fmt.Errorf("could not write tar content for %s: %w", filename, err)
+	}
+	return SaveResult{Location: "tar:" + filename, SizeBytes: int64(len(content)), SHA256: hex.EncodeToString(sum[:])}, nil
+}
+
+func (s *tarSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tw.Close()
+}
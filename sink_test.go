@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"mime"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fmurodov/pdf-from-eml/emlparse"
+)
+
+func TestDirSinkSave(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "test_dir_sink")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	content := "hello world"
+	part := &emlparse.MIMEPart{Reader: strings.NewReader(content)}
+
+	sink := dirSink{outputDir: tempDir}
+	result, err := sink.Save("doc.pdf", part)
+	if err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	if result.Location != filepath.Join(tempDir, "doc.pdf") {
+		t.Errorf("Location = %q", result.Location)
+	}
+	if result.SizeBytes != int64(len(content)) {
+		t.Errorf("SizeBytes = %d, want %d", result.SizeBytes, len(content))
+	}
+	wantSum := sha256.Sum256([]byte(content))
+	if result.SHA256 != hex.EncodeToString(wantSum[:]) {
+		t.Errorf("SHA256 = %q, want %q", result.SHA256, hex.EncodeToString(wantSum[:]))
+	}
+
+	got, err := os.ReadFile(result.Location)
+	if err != nil {
+		t.Fatalf("reading saved file: %v", err)
+	}
+	if string(got) != content {
+		t.Errorf("file content = %q, want %q", got, content)
+	}
+}
+
+func TestMultipartSinkSave(t *testing.T) {
+	var buf bytes.Buffer
+	sink := newMultipartSink(&buf)
+
+	content := "pdf-bytes"
+	part := &emlparse.MIMEPart{ContentType: "application/pdf", Reader: strings.NewReader(content)}
+
+	result, err := sink.Save("doc.pdf", part)
+	if err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if result.SizeBytes != int64(len(content)) {
+		t.Errorf("SizeBytes = %d, want %d", result.SizeBytes, len(content))
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	mr := multipart.NewReader(&buf, sink.Boundary())
+	p, err := mr.NextPart()
+	if err != nil {
+		t.Fatalf("NextPart: %v", err)
+	}
+	_, params, err := mime.ParseMediaType(p.Header.Get("Content-Disposition"))
+	if err != nil {
+		t.Fatalf("ParseMediaType: %v", err)
+	}
+	if params["filename"] != "doc.pdf" {
+		t.Errorf("filename = %q, want %q", params["filename"], "doc.pdf")
+	}
+	got, err := io.ReadAll(p)
+	if err != nil {
+		t.Fatalf("reading part: %v", err)
+	}
+	if string(got) != content {
+		t.Errorf("part content = %q, want %q", got, content)
+	}
+}
+
+func TestTarSinkSave(t *testing.T) {
+	var buf bytes.Buffer
+	sink := newTarSink(&buf)
+
+	content := "tar-bytes"
+	part := &emlparse.MIMEPart{ContentType: "application/pdf", Reader: strings.NewReader(content)}
+
+	if _, err := sink.Save("doc.pdf", part); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	if buf.Len() == 0 {
+		t.Error("expected tar output to be non-empty")
+	}
+}
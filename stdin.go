@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+)
+
+// runStdinMode reads a single EML message from stdin and extracts its
+// attachments, either to outputDir (the default) or, with stdout set, as
+// a single streamed archive on os.Stdout in the given format ("multipart"
+// or "tar"). Status and progress messages always go to stderr so stdout
+// mode can be piped without interleaving.
+func runStdinMode(outputDir, manifestPath string, stdout bool, format string) error {
+	log.SetOutput(os.Stderr)
+
+	if !stdout {
+		if err := os.MkdirAll(outputDir, 0755); err != nil {
+			return fmt.Errorf("creating output directory '%s': %w", outputDir, err)
+		}
+		manifestWriter := NewManifestWriter(manifestPath)
+		count, err := ExtractFromReader(os.Stdin, "-", dirSink{outputDir: outputDir}, manifestWriter)
+		if err != nil {
+			return err
+		}
+		if err := manifestWriter.Close(); err != nil {
+			return fmt.Errorf("writing manifest: %w", err)
+		}
+		fmt.Fprintf(os.Stderr, "Finished! Extracted %d attachment(s).\n", count)
+		return nil
+	}
+
+	manifestWriter := NewManifestWriter(manifestPath)
+	sink, closeSink, err := newStdoutSink(format, os.Stdout)
+	if err != nil {
+		return err
+	}
+
+	count, err := ExtractFromReader(os.Stdin, "-", sink, manifestWriter)
+	if closeErr := closeSink(); closeErr != nil && err == nil {
+		err = fmt.Errorf("closing output stream: %w", closeErr)
+	}
+	if err != nil {
+		return err
+	}
+	if err := manifestWriter.Close(); err != nil {
+		return fmt.Errorf("writing manifest: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Finished! Extracted %d attachment(s).\n", count)
+	return nil
+}
+
+// newStdoutSink builds the AttachmentSink for -stdin -stdout mode matching
+// format, along with a close function to flush it once extraction is
+// done. It reports the stream's framing on stderr so a caller piping
+// stdout elsewhere knows how to unpack it.
+func newStdoutSink(format string, w io.Writer) (AttachmentSink, func() error, error) {
+	switch format {
+	case "multipart":
+		sink := newMultipartSink(w)
+		fmt.Fprintf(os.Stderr, "Streaming attachments as multipart/mixed, boundary=%s\n", sink.Boundary())
+		return sink, sink.Close, nil
+	case "tar":
+		sink := newTarSink(w)
+		return sink, sink.Close, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown -format %q: want \"multipart\" or \"tar\"", format)
+	}
+}